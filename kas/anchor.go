@@ -17,14 +17,14 @@
 package kas
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"github.com/klaytn/klaytn/blockchain/types"
-	"github.com/klaytn/klaytn/common"
 	"math/big"
-	"net/http"
+	"sync"
+	"time"
+
+	"github.com/klaytn/klaytn/blockchain/types"
 )
 
 const (
@@ -34,12 +34,25 @@ const (
 var (
 	errNotFoundBlock      = errors.New("not found block")
 	errInvalidBlockNumber = errors.New("invalid block number")
+	errNoPendingAnchor    = errors.New("no pending anchor payload")
 )
 
 //go:generate mockgen -destination=./mocks/anchordb_mock.go -package=mocks github.com/klaytn/klaytn/kas AnchorDB
 type AnchorDB interface {
 	WriteAnchoredBlockNumber(blockNum uint64)
 	ReadAnchoredBlockNumber() uint64
+
+	// EnqueuePendingAnchor buffers a payload that could not be sent while
+	// the circuit breaker was open, so it can be replayed later.
+	EnqueuePendingAnchor(payload *Payload) error
+	// DequeuePendingAnchor pops the oldest buffered payload. It returns
+	// errNoPendingAnchor when the queue is empty.
+	DequeuePendingAnchor() (*Payload, error)
+
+	// ReadAnchoredBlocks returns the anchored block numbers within [from, to]
+	// as a sorted, non-overlapping list of inclusive ranges, so large
+	// contiguous spans don't need to be enumerated one block at a time.
+	ReadAnchoredBlocks(from, to uint64) []Range
 }
 
 //go:generate mockgen -destination=./mocks/blockchain_mock.go -package=mocks github.com/klaytn/klaytn/kas BlockChain
@@ -47,30 +60,52 @@ type BlockChain interface {
 	GetBlockByNumber(number uint64) *types.Block
 }
 
-//go:generate mockgen -destination=./mocks/client_mock.go -package=mocks github.com/klaytn/klaytn/kas HTTPClient
-type HTTPClient interface {
-	Do(req *http.Request) (*http.Response, error)
-}
-
 type Anchor struct {
 	kasConfig *KASConfig
 	db        AnchorDB
 	bc        BlockChain
-	client    HTTPClient
+	sinks     []AnchorSink
+
+	batchMu   sync.Mutex
+	batch     []*batchItem
+	batchOnce sync.Once
+	stopCh    chan struct{}
+	flushWg   sync.WaitGroup
+	closeOnce sync.Once
 }
 
-func NewKASAnchor(kasConfig *KASConfig, db AnchorDB, bc BlockChain) *Anchor {
+// NewKASAnchor builds an Anchor that always anchors via the KAS HTTP API,
+// additionally fanning out to (or falling back to, per KASConfig.SinkMode) a
+// Kafka topic and/or an on-chain anchor contract when configured. It returns
+// an error if the Kafka sink is configured without partitions, rather than
+// failing later when an anchor is first sent; the contract sink's config is
+// not validated here.
+func NewKASAnchor(kasConfig *KASConfig, db AnchorDB, bc BlockChain) (*Anchor, error) {
+	kasConfig.setDefaults()
+
+	sinks := []AnchorSink{newKASHTTPSink(kasConfig)}
+	if kasConfig.Kafka != nil {
+		kafka, err := newKafkaSink(kasConfig.Kafka)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kas anchor config: %w", err)
+		}
+		sinks = append(sinks, kafka)
+	}
+	if kasConfig.Contract != nil {
+		sinks = append(sinks, newContractSink(kasConfig.Contract))
+	}
+
 	return &Anchor{
 		kasConfig: kasConfig,
 		db:        db,
 		bc:        bc,
-		client:    &http.Client{},
-	}
+		sinks:     sinks,
+	}, nil
 }
 
 // AnchorPeriodicBlock periodically anchor blocks to KAS.
 // if given block is invalid, it does nothing.
-func (anchor *Anchor) AnchorPeriodicBlock(block *types.Block) {
+func (anchor *Anchor) AnchorPeriodicBlock(ctx context.Context, block *types.Block) {
 	if !anchor.kasConfig.Anchor {
 		return
 	}
@@ -84,9 +119,11 @@ func (anchor *Anchor) AnchorPeriodicBlock(block *types.Block) {
 		return
 	}
 
-	if err := anchor.AnchorBlock(block); err != nil {
+	if err := anchor.AnchorBlock(ctx, block); err != nil {
 		logger.Warn("Failed to anchor a block via KAS", "blkNum", block.NumberU64())
 	}
+
+	anchorLagBlocksGauge.Update(int64(block.NumberU64()) - int64(anchor.db.ReadAnchoredBlockNumber()))
 }
 
 // blockToAnchoringDataInternalType0 makes AnchoringDataInternalType0 from the given block.
@@ -120,87 +157,253 @@ func (anchor *Anchor) blockToAnchoringDataInternalType0(block *types.Block) *typ
 }
 
 // AnchorBlock converts given block to payload and anchor the payload via KAS anchor API.
-func (anchor *Anchor) AnchorBlock(block *types.Block) error {
+func (anchor *Anchor) AnchorBlock(ctx context.Context, block *types.Block) error {
+	ctx, span := tracer.Start(ctx, "Anchor.AnchorBlock")
+	defer span.End()
+
+	anchor.drainPendingAnchors(ctx)
+
 	anchorData := anchor.blockToAnchoringDataInternalType0(block)
 
 	payload := dataToPayload(anchorData)
 
-	res, err := anchor.sendRequest(payload)
+	span.SetAttributes(anchorSpanAttributes(block.NumberU64(), anchorData.TxCount.Int64(), anchor.kasConfig.Xkrn)...)
+
+	return anchor.anchorPayload(ctx, payload, block.NumberU64())
+}
+
+// anchorPayload sends the given payload to the configured sinks. If the
+// primary KAS sink's circuit breaker is open, the payload is buffered in
+// AnchorDB instead of being dropped, so it can be replayed once the breaker
+// closes again.
+func (anchor *Anchor) anchorPayload(ctx context.Context, payload *Payload, blkNum uint64) error {
+	results, err := anchor.sendToSinks(ctx, []*Payload{payload})
+	if err == errBreakerOpen {
+		if qErr := anchor.db.EnqueuePendingAnchor(payload); qErr != nil {
+			logger.Error("Failed to enqueue pending anchor while breaker is open", "blkNum", blkNum, "err", qErr)
+		} else {
+			anchorPendingQueueDepthGauge.Inc(1)
+		}
+		return err
+	}
 	if err != nil {
 		return err
 	}
 
-	if res.Code != codeOK {
-		result, _ := json.Marshal(res)
-		logger.Debug("Failed to anchor a block via KAS", "blkNum", block.NumberU64(), "result", string(result))
-		return fmt.Errorf("error code %v", res.Code)
+	if len(results) == 0 || !results[0].Success {
+		logger.Debug("Failed to anchor a block via KAS", "blkNum", blkNum)
+		return fmt.Errorf("anchor sink reported failure for block %v", blkNum)
 	}
 
-	logger.Info("Anchored a block via KAS", "blkNum", block.NumberU64())
+	anchor.db.WriteAnchoredBlockNumber(blkNum)
+	anchorLastAnchoredBlockGauge.Update(int64(blkNum))
+	logger.Info("Anchored a block via KAS", "blkNum", blkNum)
 	return nil
 }
 
-type respBody struct {
-	Code   int         `json:"code"`
-	Result interface{} `json:"result"`
+// drainPendingAnchors replays payloads buffered while the circuit breaker
+// was open, stopping at the first failure so the breaker state stays
+// authoritative over whether it's safe to keep draining.
+func (anchor *Anchor) drainPendingAnchors(ctx context.Context) {
+	for {
+		payload, err := anchor.db.DequeuePendingAnchor()
+		if err != nil {
+			return
+		}
+		anchorPendingQueueDepthGauge.Dec(1)
+
+		if _, err := anchor.sendToSinks(ctx, []*Payload{payload}); err != nil {
+			if qErr := anchor.db.EnqueuePendingAnchor(payload); qErr != nil {
+				logger.Error("Failed to re-enqueue pending anchor", "err", qErr)
+			} else {
+				anchorPendingQueueDepthGauge.Inc(1)
+			}
+			return
+		}
+	}
 }
 
-type reqBody struct {
-	Operator common.Address `json:"operator"`
-	Payload  interface{}    `json:"Payload"`
+// batchItem pairs a payload with the block number it was derived from, so a
+// successful batch flush knows how far WriteAnchoredBlockNumber may advance.
+type batchItem struct {
+	payload *Payload
+	blkNum  uint64
 }
 
-type Payload struct {
-	Id string `json:"id"`
-	types.AnchoringDataInternalType0
-}
+// AnchorBlockBatched converts the given block to a payload and appends it to
+// an in-memory buffer instead of anchoring it immediately. The buffer is
+// flushed once it reaches KASConfig.BatchSize or KASConfig.BatchFlushInterval
+// elapses, whichever comes first.
+func (anchor *Anchor) AnchorBlockBatched(ctx context.Context, block *types.Block) error {
+	anchor.ensureBatchFlusher()
 
-// dataToPayload wraps given AnchoringDataInternalType0 to payload with `id` field.
-func dataToPayload(anchorData *types.AnchoringDataInternalType0) *Payload {
-	payload := &Payload{
-		Id:                         anchorData.BlockNumber.String(),
-		AnchoringDataInternalType0: *anchorData,
+	anchorData := anchor.blockToAnchoringDataInternalType0(block)
+	payload := dataToPayload(anchorData)
+
+	anchor.batchMu.Lock()
+	anchor.batch = append(anchor.batch, &batchItem{payload: payload, blkNum: block.NumberU64()})
+	shouldFlush := len(anchor.batch) >= anchor.kasConfig.BatchSize
+	depth := len(anchor.batch)
+	anchor.batchMu.Unlock()
+	anchorBatchQueueDepthGauge.Update(int64(depth))
+
+	if shouldFlush {
+		return anchor.flushBatch(ctx)
 	}
+	return nil
+}
 
-	return payload
+// ensureBatchFlusher lazily starts the background goroutine that flushes the
+// batch buffer on a timer, so anchors using only AnchorBlock never pay for an
+// idle goroutine.
+func (anchor *Anchor) ensureBatchFlusher() {
+	anchor.batchOnce.Do(func() {
+		anchor.stopCh = make(chan struct{})
+		anchor.flushWg.Add(1)
+		go anchor.runBatchFlusher()
+	})
+}
+
+func (anchor *Anchor) runBatchFlusher() {
+	defer anchor.flushWg.Done()
+
+	ticker := time.NewTicker(anchor.kasConfig.BatchFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := anchor.flushBatch(context.Background()); err != nil {
+				logger.Warn("Failed to flush KAS anchor batch", "err", err)
+			}
+		case <-anchor.stopCh:
+			return
+		}
+	}
 }
 
-// sendRequest requests to KAS anchor API with given payload.
-func (anchor *Anchor) sendRequest(payload interface{}) (*respBody, error) {
-	header := map[string]string{
-		"Content-Type": "application/json",
-		"X-Krn":        anchor.kasConfig.Xkrn,
+// flushBatch sends the currently buffered payloads as a single batch
+// request. WriteAnchoredBlockNumber is advanced to the highest block number
+// in the longest successful prefix of the batch; any item from the first
+// failure onward is re-buffered so it's retried on the next flush. If the
+// primary KAS sink's circuit breaker is open, the items are instead persisted
+// via AnchorDB, same as the non-batched path, so they survive a crash/restart
+// while the breaker is open.
+func (anchor *Anchor) flushBatch(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Anchor.flushBatch")
+	defer span.End()
+
+	anchor.drainPendingAnchors(ctx)
+
+	anchor.batchMu.Lock()
+	items := anchor.batch
+	anchor.batch = nil
+	anchor.batchMu.Unlock()
+	anchorBatchQueueDepthGauge.Update(0)
+
+	if len(items) == 0 {
+		return nil
 	}
 
-	bodyData := reqBody{
-		Operator: anchor.kasConfig.Operator,
-		Payload:  payload,
+	payloads := make([]*Payload, len(items))
+	txCount := int64(0)
+	for i, item := range items {
+		payloads[i] = item.payload
+		txCount += item.payload.TxCount.Int64()
 	}
+	span.SetAttributes(anchorSpanAttributes(items[len(items)-1].blkNum, txCount, anchor.kasConfig.Xkrn)...)
 
-	bodyDataBytes, err := json.Marshal(bodyData)
+	results, err := anchor.sendToSinks(ctx, payloads)
+	if err == errBreakerOpen {
+		anchor.enqueuePendingBatch(items)
+		return err
+	}
 	if err != nil {
-		return nil, err
+		anchor.requeueBatch(items)
+		return err
 	}
 
-	body := bytes.NewReader(bodyDataBytes)
+	succeeded := 0
+	for succeeded < len(items) && succeeded < len(results) && results[succeeded].Success {
+		succeeded++
+	}
 
-	req, err := http.NewRequest("POST", anchor.kasConfig.Url, body)
-	if err != nil {
-		return nil, err
+	if succeeded > 0 {
+		anchor.db.WriteAnchoredBlockNumber(items[succeeded-1].blkNum)
+		anchorLastAnchoredBlockGauge.Update(int64(items[succeeded-1].blkNum))
+		for _, item := range items[:succeeded] {
+			logger.Info("Anchored a block via KAS batch", "blkNum", item.blkNum)
+		}
 	}
-	req.SetBasicAuth(anchor.kasConfig.User, anchor.kasConfig.Pwd)
-	for k, v := range header {
-		req.Header.Set(k, v)
+
+	if failed := items[succeeded:]; len(failed) > 0 {
+		anchor.requeueBatch(failed)
+		return fmt.Errorf("%d of %d anchor payloads failed in batch", len(failed), len(items))
 	}
 
-	resp, err := anchor.client.Do(req)
-	if err != nil {
-		return nil, err
+	return nil
+}
+
+func (anchor *Anchor) requeueBatch(items []*batchItem) {
+	anchor.batchMu.Lock()
+	defer anchor.batchMu.Unlock()
+	anchor.batch = append(items, anchor.batch...)
+	anchorBatchQueueDepthGauge.Update(int64(len(anchor.batch)))
+}
+
+// enqueuePendingBatch persists items via AnchorDB instead of re-buffering
+// them in memory, so they aren't lost if the process crashes while the
+// circuit breaker is open. They're replayed by drainPendingAnchors the next
+// time AnchorBlock or flushBatch runs. Any item AnchorDB fails to persist
+// falls back to the in-memory batch so it isn't dropped outright.
+func (anchor *Anchor) enqueuePendingBatch(items []*batchItem) {
+	var unpersisted []*batchItem
+	for _, item := range items {
+		if qErr := anchor.db.EnqueuePendingAnchor(item.payload); qErr != nil {
+			logger.Error("Failed to enqueue pending batch anchor while breaker is open", "blkNum", item.blkNum, "err", qErr)
+			unpersisted = append(unpersisted, item)
+			continue
+		}
+		anchorPendingQueueDepthGauge.Inc(1)
+	}
+	if len(unpersisted) > 0 {
+		anchor.requeueBatch(unpersisted)
 	}
-	defer resp.Body.Close()
+}
 
-	v := respBody{}
-	json.NewDecoder(resp.Body).Decode(&v)
+// Close stops the background batch flusher (if any), performs a final
+// flush so no buffered anchor is lost on shutdown, and closes every
+// configured sink.
+func (anchor *Anchor) Close() error {
+	anchor.closeOnce.Do(func() {
+		if anchor.stopCh != nil {
+			close(anchor.stopCh)
+			anchor.flushWg.Wait()
+		}
+	})
 
-	return &v, nil
-}
\ No newline at end of file
+	flushErr := anchor.flushBatch(context.Background())
+
+	for _, sink := range anchor.sinks {
+		if err := sink.Close(); err != nil {
+			logger.Error("Failed to close anchor sink", "sink", sink.Name(), "err", err)
+		}
+	}
+
+	return flushErr
+}
+
+type Payload struct {
+	Id string `json:"id"`
+	types.AnchoringDataInternalType0
+}
+
+// dataToPayload wraps given AnchoringDataInternalType0 to payload with `id` field.
+func dataToPayload(anchorData *types.AnchoringDataInternalType0) *Payload {
+	payload := &Payload{
+		Id:                         anchorData.BlockNumber.String(),
+		AnchoringDataInternalType0: *anchorData,
+	}
+
+	return payload
+}
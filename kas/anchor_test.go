@@ -18,6 +18,7 @@ package kas
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/golang/mock/gomock"
@@ -31,13 +32,87 @@ import (
 	"math/rand"
 	"net/http"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 var (
 	errTest = errors.New("test error")
 )
 
+// fakeAnchorDB is a minimal in-memory AnchorDB used by batching/queueing
+// tests that don't need a full gomock expectation set.
+type fakeAnchorDB struct {
+	mu             sync.Mutex
+	written        uint64
+	pending        []*Payload
+	anchoredBlocks []Range
+	failEnqueue    bool
+}
+
+func (f *fakeAnchorDB) WriteAnchoredBlockNumber(blockNum uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if blockNum > f.written {
+		f.written = blockNum
+	}
+}
+
+func (f *fakeAnchorDB) ReadAnchoredBlockNumber() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.written
+}
+
+func (f *fakeAnchorDB) EnqueuePendingAnchor(payload *Payload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failEnqueue {
+		return errTest
+	}
+	f.pending = append(f.pending, payload)
+	return nil
+}
+
+func (f *fakeAnchorDB) DequeuePendingAnchor() (*Payload, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.pending) == 0 {
+		return nil, errNoPendingAnchor
+	}
+	payload := f.pending[0]
+	f.pending = f.pending[1:]
+	return payload, nil
+}
+
+func (f *fakeAnchorDB) ReadAnchoredBlocks(from, to uint64) []Range {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out []Range
+	for _, r := range f.anchoredBlocks {
+		if r.To < from || r.From > to {
+			continue
+		}
+		clipped := r
+		if clipped.From < from {
+			clipped.From = from
+		}
+		if clipped.To > to {
+			clipped.To = to
+		}
+		out = append(out, clipped)
+	}
+	return out
+}
+
+// kasSink returns the Anchor's primary KAS HTTP sink, which the tests here
+// poke directly, mostly to install a mock HTTPClient.
+func kasSink(anchor *Anchor) *kasHTTPSink {
+	return anchor.sinks[0].(*kasHTTPSink)
+}
+
 func testAnchorData() *types.AnchoringDataInternalType0 {
 	return &types.AnchoringDataInternalType0{
 		BlockHash:     common.HexToHash("0"),
@@ -72,10 +147,11 @@ func TestExampleSendRequest(t *testing.T) {
 		AnchorPeriod: 1,
 	}
 
-	kasAnchor := NewKASAnchor(kasConfig, nil, nil)
+	kasAnchor, err := NewKASAnchor(kasConfig, nil, nil)
+	assert.NoError(t, err)
 
 	payload := dataToPayload(anchorData)
-	res, err := kasAnchor.sendRequest(payload)
+	res, err := kasSink(kasAnchor).sendRequest(context.Background(), payload)
 	assert.NoError(t, err)
 
 	result, err := json.Marshal(res)
@@ -85,19 +161,21 @@ func TestExampleSendRequest(t *testing.T) {
 }
 
 func TestSendRequest(t *testing.T) {
-	config := KASConfig{}
-	anchor := NewKASAnchor(&config, nil, nil)
+	config := KASConfig{RetryBaseDelay: time.Millisecond, RetryMaxDelay: time.Millisecond}
+	anchor, err := NewKASAnchor(&config, nil, nil)
+	assert.NoError(t, err)
+	sink := kasSink(anchor)
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := mocks.NewMockHTTPClient(ctrl)
-	anchor.client = m
+	sink.client = m
 
 	anchorData := testAnchorData()
 	pl := dataToPayload(anchorData)
 
 	// OK case
 	{
-		expectedRes := http.Response{Status: strconv.Itoa(http.StatusOK)}
+		expectedRes := http.Response{Status: strconv.Itoa(http.StatusOK), StatusCode: http.StatusOK}
 		expectedRespBody := respBody{
 			Code: 0,
 		}
@@ -105,20 +183,80 @@ func TestSendRequest(t *testing.T) {
 		expectedRes.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
 
 		m.EXPECT().Do(gomock.Any()).Times(1).Return(&expectedRes, nil)
-		resp, err := anchor.sendRequest(pl)
+		resp, err := sink.sendRequest(context.Background(), pl)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedRespBody.Code, resp.Code)
 	}
 
-	// Error case
+	// Error case: a network error is attempted MaxRetries times in all before
+	// being surfaced and tripping the breaker's failure count.
 	{
-		m.EXPECT().Do(gomock.Any()).Times(1).Return(nil, errTest)
-		resp, err := anchor.sendRequest(pl)
+		m.EXPECT().Do(gomock.Any()).Times(config.MaxRetries).Return(nil, errTest)
+		resp, err := sink.sendRequest(context.Background(), pl)
 
 		assert.Error(t, errTest, err)
 		assert.Nil(t, resp)
 	}
+
+	// Non-retryable 4xx case: only a single attempt is made.
+	{
+		expectedRes := http.Response{Status: strconv.Itoa(http.StatusBadRequest), StatusCode: http.StatusBadRequest}
+		expectedRes.Body = ioutil.NopCloser(bytes.NewReader(nil))
+
+		m.EXPECT().Do(gomock.Any()).Times(1).Return(&expectedRes, nil)
+		resp, err := sink.sendRequest(context.Background(), pl)
+
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+	}
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	config := KASConfig{
+		MaxRetries:       1,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    time.Millisecond,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+	}
+	anchor, err := NewKASAnchor(&config, nil, nil)
+	assert.NoError(t, err)
+	sink := kasSink(anchor)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockHTTPClient(ctrl)
+	sink.client = m
+
+	pl := dataToPayload(testAnchorData())
+
+	m.EXPECT().Do(gomock.Any()).Times(2).Return(nil, errTest)
+	for i := 0; i < 2; i++ {
+		_, err := sink.sendRequest(context.Background(), pl)
+		assert.Error(t, err)
+	}
+
+	// Breaker is now open: no further HTTP calls should be made.
+	_, err := sink.sendRequest(context.Background(), pl)
+	assert.Equal(t, errBreakerOpen, err)
+}
+
+func TestCircuitBreakerAllowsOnlyOneProbeWhileHalfOpen(t *testing.T) {
+	b := newCircuitBreaker(1, time.Millisecond)
+
+	b.RecordFailure()
+	assert.False(t, b.Allow())
+
+	time.Sleep(2 * time.Millisecond)
+
+	// Cooldown elapsed: exactly one caller should see the probe through;
+	// every other concurrent caller must be refused until it resolves.
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	b.RecordFailure()
+	assert.False(t, b.Allow())
 }
 
 func TestDataToPayload(t *testing.T) {
@@ -146,7 +284,8 @@ func testBlockToAnchoringDataInternalType0(t *testing.T, period uint64) {
 	defer ctrl.Finish()
 	bc := mocks.NewMockBlockChain(ctrl)
 
-	anchor := NewKASAnchor(&config, nil, bc)
+	anchor, err := NewKASAnchor(&config, nil, bc)
+	assert.NoError(t, err)
 	testBlkN := uint64(100)
 	pastCnt := [100]uint64{}
 	txCnt := uint64(0)
@@ -192,4 +331,262 @@ func genTransactions(n uint64) (types.Transactions, error) {
 	}
 
 	return txs, nil
-}
\ No newline at end of file
+}
+
+func TestAnchorBlockBatchedFlushesOnBatchSize(t *testing.T) {
+	config := KASConfig{
+		Anchor:             true,
+		AnchorPeriod:       1,
+		BatchSize:          2,
+		BatchFlushInterval: time.Hour,
+	}
+	db := &fakeAnchorDB{}
+	anchor, err := NewKASAnchor(&config, db, nil)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockHTTPClient(ctrl)
+	kasSink(anchor).client = m
+
+	expectedRespBody := batchRespBody{Code: codeOK, Result: []respItem{{Code: codeOK}, {Code: codeOK}}}
+	bodyBytes, _ := json.Marshal(expectedRespBody)
+	httpResp := &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(bodyBytes))}
+	m.EXPECT().Do(gomock.Any()).Times(1).Return(httpResp, nil)
+
+	block1 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	block2 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)})
+
+	assert.NoError(t, anchor.AnchorBlockBatched(context.Background(), block1))
+	assert.NoError(t, anchor.AnchorBlockBatched(context.Background(), block2))
+
+	assert.Equal(t, uint64(2), db.ReadAnchoredBlockNumber())
+}
+
+func TestFlushBatchRequeuesOnlyFailedItems(t *testing.T) {
+	config := KASConfig{
+		Anchor:             true,
+		AnchorPeriod:       1,
+		BatchSize:          10,
+		BatchFlushInterval: time.Hour,
+	}
+	db := &fakeAnchorDB{}
+	anchor, err := NewKASAnchor(&config, db, nil)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockHTTPClient(ctrl)
+	kasSink(anchor).client = m
+
+	expectedRespBody := batchRespBody{Code: codeOK, Result: []respItem{{Code: codeOK}, {Code: 1}}}
+	bodyBytes, _ := json.Marshal(expectedRespBody)
+	httpResp := &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(bodyBytes))}
+	m.EXPECT().Do(gomock.Any()).Times(1).Return(httpResp, nil)
+
+	block1 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	block2 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)})
+
+	assert.NoError(t, anchor.AnchorBlockBatched(context.Background(), block1))
+	assert.NoError(t, anchor.AnchorBlockBatched(context.Background(), block2))
+
+	err = anchor.flushBatch(context.Background())
+	assert.Error(t, err)
+
+	assert.Equal(t, uint64(1), db.ReadAnchoredBlockNumber())
+
+	anchor.batchMu.Lock()
+	requeued := len(anchor.batch)
+	anchor.batch = nil // drop the requeued item so the deferred Close doesn't trigger another unexpected HTTP call
+	anchor.batchMu.Unlock()
+	assert.Equal(t, 1, requeued)
+}
+
+func TestFlushBatchPersistsToAnchorDBWhenBreakerOpen(t *testing.T) {
+	config := KASConfig{
+		Anchor:             true,
+		AnchorPeriod:       1,
+		BatchSize:          10,
+		BatchFlushInterval: time.Hour,
+		MaxRetries:         1,
+		RetryBaseDelay:     time.Millisecond,
+		RetryMaxDelay:      time.Millisecond,
+		BreakerThreshold:   1,
+		BreakerCooldown:    time.Hour,
+	}
+	db := &fakeAnchorDB{}
+	anchor, err := NewKASAnchor(&config, db, nil)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockHTTPClient(ctrl)
+	kasSink(anchor).client = m
+
+	// Trip the breaker open with a single failing request.
+	m.EXPECT().Do(gomock.Any()).Times(1).Return(nil, errTest)
+	_, tripErr := kasSink(anchor).sendRequest(context.Background(), dataToPayload(testAnchorData()))
+	assert.Error(t, tripErr)
+
+	block1 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	assert.NoError(t, anchor.AnchorBlockBatched(context.Background(), block1))
+
+	err = anchor.flushBatch(context.Background())
+	assert.Equal(t, errBreakerOpen, err)
+
+	assert.Len(t, db.pending, 1)
+
+	anchor.batchMu.Lock()
+	depth := len(anchor.batch)
+	anchor.batchMu.Unlock()
+	assert.Equal(t, 0, depth)
+}
+
+func TestFlushBatchKeepsItemInMemoryWhenAnchorDBPersistFails(t *testing.T) {
+	config := KASConfig{
+		Anchor:             true,
+		AnchorPeriod:       1,
+		BatchSize:          10,
+		BatchFlushInterval: time.Hour,
+		MaxRetries:         1,
+		RetryBaseDelay:     time.Millisecond,
+		RetryMaxDelay:      time.Millisecond,
+		BreakerThreshold:   1,
+		BreakerCooldown:    time.Hour,
+	}
+	db := &fakeAnchorDB{}
+	anchor, err := NewKASAnchor(&config, db, nil)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockHTTPClient(ctrl)
+	kasSink(anchor).client = m
+
+	// Trip the breaker open with a single failing request.
+	m.EXPECT().Do(gomock.Any()).Times(1).Return(nil, errTest)
+	_, tripErr := kasSink(anchor).sendRequest(context.Background(), dataToPayload(testAnchorData()))
+	assert.Error(t, tripErr)
+
+	block1 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	assert.NoError(t, anchor.AnchorBlockBatched(context.Background(), block1))
+
+	db.failEnqueue = true
+	err = anchor.flushBatch(context.Background())
+	assert.Equal(t, errBreakerOpen, err)
+
+	assert.Empty(t, db.pending)
+
+	anchor.batchMu.Lock()
+	depth := len(anchor.batch)
+	anchor.batch = nil // drop it so the deferred Close doesn't retry the persist failure
+	anchor.batchMu.Unlock()
+	assert.Equal(t, 1, depth)
+}
+
+func TestDetectGapsFindsMissedPeriods(t *testing.T) {
+	config := KASConfig{
+		Anchor:       true,
+		AnchorPeriod: 10,
+	}
+	db := &fakeAnchorDB{
+		written:        50,
+		anchoredBlocks: []Range{{From: 1, To: 50}, {From: 71, To: 80}},
+	}
+	anchor, err := NewKASAnchor(&config, db, nil)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	gaps := anchor.DetectGaps(100)
+
+	assert.Equal(t, []Range{{From: 60, To: 70}, {From: 90, To: 100}}, gaps)
+}
+
+func TestDetectGapsReturnsNilWhenUpToDate(t *testing.T) {
+	config := KASConfig{
+		Anchor:       true,
+		AnchorPeriod: 10,
+	}
+	db := &fakeAnchorDB{written: 100}
+	anchor, err := NewKASAnchor(&config, db, nil)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	assert.Nil(t, anchor.DetectGaps(100))
+	assert.Nil(t, anchor.DetectGaps(50))
+}
+
+func TestAnchorPeriodicBlockAdvancesAnchoredBlockNumber(t *testing.T) {
+	config := KASConfig{
+		Anchor:       true,
+		AnchorPeriod: 1,
+	}
+	db := &fakeAnchorDB{}
+	anchor, err := NewKASAnchor(&config, db, nil)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockHTTPClient(ctrl)
+	kasSink(anchor).client = m
+
+	expectedRespBody := respBody{Code: codeOK}
+	bodyBytes, _ := json.Marshal(expectedRespBody)
+	m.EXPECT().Do(gomock.Any()).Times(1).DoAndReturn(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(bodyBytes))}, nil
+	})
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)})
+	anchor.AnchorPeriodicBlock(context.Background(), block)
+
+	// AnchorPeriodicBlock computes anchorLagBlocksGauge from
+	// ReadAnchoredBlockNumber, so a successful anchor must advance it or the
+	// lag gauge never reflects real progress.
+	assert.Equal(t, uint64(5), db.ReadAnchoredBlockNumber())
+}
+
+func TestBackfillRangeAnchorsEveryPeriodInRange(t *testing.T) {
+	config := KASConfig{
+		Anchor:       true,
+		AnchorPeriod: 10,
+	}
+	db := &fakeAnchorDB{}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	bc := mocks.NewMockBlockChain(ctrl)
+
+	for blkNum := uint64(1); blkNum <= 30; blkNum++ {
+		header := &types.Header{Number: big.NewInt(int64(blkNum))}
+		bc.EXPECT().GetBlockByNumber(blkNum).Return(types.NewBlockWithHeader(header)).AnyTimes()
+	}
+
+	anchor, err := NewKASAnchor(&config, db, bc)
+	assert.NoError(t, err)
+	defer anchor.Close()
+
+	m := mocks.NewMockHTTPClient(ctrl)
+	kasSink(anchor).client = m
+
+	expectedRespBody := respBody{Code: codeOK}
+	bodyBytes, _ := json.Marshal(expectedRespBody)
+	m.EXPECT().Do(gomock.Any()).Times(3).DoAndReturn(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(bodyBytes))}, nil
+	})
+
+	assert.Equal(t, []Range{{From: 10, To: 30}}, anchor.DetectGaps(30))
+
+	err = anchor.BackfillRange(context.Background(), 10, 30, 2)
+	assert.NoError(t, err)
+
+	// A successful backfill must persist the anchored block number, or
+	// DetectGaps would report the very same range as missing again on the
+	// next call or node restart.
+	assert.Equal(t, uint64(30), db.ReadAnchoredBlockNumber())
+	assert.Nil(t, anchor.DetectGaps(30))
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import "context"
+
+// PrivateAdminAPI exposes operator-only KAS anchor controls over the "admin"
+// RPC namespace.
+type PrivateAdminAPI struct {
+	anchor *Anchor
+}
+
+// NewPrivateAdminAPI returns a new PrivateAdminAPI backed by the given Anchor.
+func NewPrivateAdminAPI(anchor *Anchor) *PrivateAdminAPI {
+	return &PrivateAdminAPI{anchor: anchor}
+}
+
+// KasBackfill re-anchors every period-aligned block in [from, to], exposed as
+// admin_kasBackfill so operators can recover missed anchors after an outage
+// without restarting the node.
+func (api *PrivateAdminAPI) KasBackfill(from, to uint64) error {
+	return api.anchor.BackfillRange(context.Background(), from, to, defaultBackfillConcurrency)
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// defaultBackfillConcurrency is the worker pool size used by the
+// admin_kasBackfill RPC entry point, which has no natural caller-supplied
+// concurrency of its own.
+const defaultBackfillConcurrency = 4
+
+// Range is an inclusive span of block numbers, e.g. a gap between two
+// anchored periods that DetectGaps found or a backfill request an operator
+// submitted.
+type Range struct {
+	From uint64
+	To   uint64
+}
+
+// BackfillRange re-anchors every period-aligned block in [from, to] using a
+// bounded pool of concurrency workers. It's meant to recover anchors that
+// were missed while the node or KAS was unavailable; DetectGaps locates the
+// ranges worth passing in here.
+func (anchor *Anchor) BackfillRange(ctx context.Context, from, to uint64, concurrency int) error {
+	ctx, span := tracer.Start(ctx, "Anchor.BackfillRange")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("from", int64(from)), attribute.Int64("to", int64(to)))
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	period := anchor.kasConfig.AnchorPeriod
+	if period == 0 {
+		period = 1
+	}
+
+	first := from
+	if rem := first % period; rem != 0 {
+		first += period - rem
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for blkNum := first; blkNum <= to; blkNum += period {
+		blkNum := blkNum
+
+		block := anchor.bc.GetBlockByNumber(blkNum)
+		if block == nil {
+			logger.Warn("Skipping backfill for missing block", "blkNum", blkNum)
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := anchor.AnchorBlock(ctx, block); err != nil {
+				logger.Warn("Failed to backfill anchor for block", "blkNum", blkNum, "err", err)
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("backfill failed at block %v: %w", blkNum, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+// DetectGaps walks the period-aligned anchor points between the last
+// anchored block and chainHead and returns the spans that AnchorDB has no
+// record of, so a backfill can be kicked off to recover them after a crash.
+func (anchor *Anchor) DetectGaps(chainHead uint64) []Range {
+	period := anchor.kasConfig.AnchorPeriod
+	if period == 0 {
+		period = 1
+	}
+
+	lastAnchored := anchor.db.ReadAnchoredBlockNumber()
+	if chainHead <= lastAnchored {
+		return nil
+	}
+
+	anchored := anchor.db.ReadAnchoredBlocks(lastAnchored+1, chainHead)
+
+	var gaps []Range
+	var gapStart, lastBlk uint64
+	inGap := false
+
+	for blkNum := lastAnchored + period; blkNum <= chainHead; blkNum += period {
+		lastBlk = blkNum
+
+		if rangesContain(anchored, blkNum) {
+			if inGap {
+				gaps = append(gaps, Range{From: gapStart, To: blkNum - period})
+				inGap = false
+			}
+			continue
+		}
+
+		if !inGap {
+			gapStart = blkNum
+			inGap = true
+		}
+	}
+
+	if inGap {
+		gaps = append(gaps, Range{From: gapStart, To: lastBlk})
+	}
+
+	return gaps
+}
+
+// BackfillGaps detects missed anchor periods up to chainHead via DetectGaps
+// and backfills each of them in turn. Callers should invoke this once at
+// startup (after a crash or restart) so outages don't leave permanent holes
+// in the anchored block history.
+func (anchor *Anchor) BackfillGaps(ctx context.Context, chainHead uint64, concurrency int) error {
+	gaps := anchor.DetectGaps(chainHead)
+	if len(gaps) == 0 {
+		return nil
+	}
+
+	logger.Info("Backfilling missed KAS anchors", "gaps", len(gaps))
+
+	for _, gap := range gaps {
+		if err := anchor.BackfillRange(ctx, gap.From, gap.To, concurrency); err != nil {
+			return fmt.Errorf("failed to backfill gap [%v, %v]: %w", gap.From, gap.To, err)
+		}
+	}
+
+	return nil
+}
+
+// rangesContain reports whether blkNum falls within any of the given
+// inclusive ranges, which are assumed sorted and non-overlapping, as
+// returned by AnchorDB.ReadAnchoredBlocks.
+func rangesContain(ranges []Range, blkNum uint64) bool {
+	for _, r := range ranges {
+		if blkNum < r.From {
+			break
+		}
+		if blkNum <= r.To {
+			return true
+		}
+	}
+	return false
+}
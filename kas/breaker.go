@@ -0,0 +1,110 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errBreakerOpen = errors.New("kas anchor: circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker is a consecutive-failure circuit breaker guarding the KAS
+// HTTP client. It trips open after `threshold` consecutive failures and
+// stays open for `cooldown` before letting a single probe request through.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports whether a request may proceed. Once the cooldown has
+// elapsed on an open breaker, it transitions to half-open and allows a
+// single probe request through; every other concurrent caller is refused
+// until that probe resolves via RecordSuccess or RecordFailure, so a
+// recovering KAS endpoint sees one request, not a thundering herd.
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerClosed:
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+
+	if time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.state = breakerHalfOpen
+	return true
+}
+
+// RecordSuccess closes the breaker and resets the failure count. It reports
+// whether the breaker was half-open, i.e. whether the success was a probe
+// that just reopened the gate for replaying buffered anchors.
+func (b *circuitBreaker) RecordSuccess() (wasHalfOpen bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasHalfOpen = b.state == breakerHalfOpen
+	b.failures = 0
+	b.state = breakerClosed
+	return wasHalfOpen
+}
+
+// RecordFailure increments the consecutive failure count, tripping the
+// breaker open once threshold is reached. A failed probe while half-open
+// re-opens the breaker immediately.
+func (b *circuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.open()
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import (
+	"time"
+
+	"github.com/klaytn/klaytn/common"
+)
+
+// Default values for the KAS anchor client's resiliency knobs. They are
+// applied by KASConfig.setDefaults whenever the corresponding field is left
+// at its zero value.
+const (
+	DefaultRequestTimeout   = 5 * time.Second
+	DefaultMaxRetries       = 3
+	DefaultRetryBaseDelay   = 200 * time.Millisecond
+	DefaultRetryMaxDelay    = 5 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+
+	DefaultBatchSize          = 20
+	DefaultBatchFlushInterval = 3 * time.Second
+)
+
+// KASConfig holds the configuration needed to anchor blocks via the KAS
+// anchor API.
+type KASConfig struct {
+	Url      string
+	Xkrn     string
+	User     string
+	Pwd      string
+	Operator common.Address
+
+	Anchor       bool
+	AnchorPeriod uint64
+
+	// RequestTimeout bounds how long a single anchor HTTP request may take.
+	RequestTimeout time.Duration
+	// MaxRetries is the maximum number of attempts made for a single anchor
+	// request, including the first one.
+	MaxRetries int
+	// RetryBaseDelay is the base delay used by the exponential backoff
+	// between retries.
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay caps the exponential backoff delay.
+	RetryMaxDelay time.Duration
+	// BreakerThreshold is the number of consecutive request failures that
+	// trips the circuit breaker open.
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open before it lets a
+	// single probe request through.
+	BreakerCooldown time.Duration
+
+	// BatchSize is the number of payloads AnchorBlockBatched buffers before
+	// flushing them as a single batch request.
+	BatchSize int
+	// BatchFlushInterval is the maximum time a buffered payload waits before
+	// being flushed, even if BatchSize hasn't been reached.
+	BatchFlushInterval time.Duration
+
+	// SinkMode selects how Anchor distributes payloads across the KAS HTTP
+	// sink plus any of Kafka/Contract configured below.
+	SinkMode SinkMode
+	// Kafka, if set, mirrors anchor payloads to a Kafka topic in addition
+	// to (or instead of, in SinkModePrimaryFallback) the KAS HTTP sink.
+	Kafka *KafkaSinkConfig
+	// Contract, if set, anchors payloads on-chain via a user-supplied
+	// anchor contract in addition to (or instead of) the KAS HTTP sink.
+	Contract *ContractSinkConfig
+}
+
+// setDefaults fills zero-valued resiliency knobs with sane defaults.
+func (c *KASConfig) setDefaults() {
+	if c.RequestTimeout == 0 {
+		c.RequestTimeout = DefaultRequestTimeout
+	}
+	// A MaxRetries <= 0 would skip executeWithRetry's loop entirely and
+	// leave sendRequest to type-assert a nil result, so clamp negative
+	// values the same as unset ones rather than only checking for zero.
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+	if c.RetryBaseDelay == 0 {
+		c.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+	if c.RetryMaxDelay == 0 {
+		c.RetryMaxDelay = DefaultRetryMaxDelay
+	}
+	if c.BreakerThreshold == 0 {
+		c.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if c.BreakerCooldown == 0 {
+		c.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.BatchFlushInterval == 0 {
+		c.BatchFlushInterval = DefaultBatchFlushInterval
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import "github.com/klaytn/klaytn/metrics"
+
+// Metrics for the KAS anchor pipeline, registered in the klaytn metrics
+// registry under the kas/anchor/... names below. Request outcomes are three
+// separate counters (requests/ok, requests/error, requests/retry); they are
+// not one counter with a result label. The request duration is a metrics
+// Timer, not a histogram in seconds.
+var (
+	anchorRequestsOKCounter    = metrics.NewRegisteredCounter("kas/anchor/requests/ok", nil)
+	anchorRequestsErrorCounter = metrics.NewRegisteredCounter("kas/anchor/requests/error", nil)
+	anchorRequestsRetryCounter = metrics.NewRegisteredCounter("kas/anchor/requests/retry", nil)
+
+	anchorRequestDurationTimer = metrics.NewRegisteredTimer("kas/anchor/request/duration", nil)
+
+	anchorLastAnchoredBlockGauge = metrics.NewRegisteredGauge("kas/anchor/lastAnchoredBlock", nil)
+	anchorLagBlocksGauge         = metrics.NewRegisteredGauge("kas/anchor/lagBlocks", nil)
+
+	// anchorPendingQueueDepthGauge tracks payloads buffered in AnchorDB while
+	// the circuit breaker is open, incremented/decremented one at a time as
+	// payloads are enqueued/dequeued.
+	anchorPendingQueueDepthGauge = metrics.NewRegisteredGauge("kas/anchor/pendingQueueDepth", nil)
+	// anchorBatchQueueDepthGauge tracks the in-memory AnchorBlockBatched
+	// buffer, set to its absolute size on every append/flush/requeue.
+	anchorBatchQueueDepthGauge = metrics.NewRegisteredGauge("kas/anchor/batchQueueDepth", nil)
+)
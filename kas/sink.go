@@ -0,0 +1,96 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import "context"
+
+//go:generate mockgen -destination=./mocks/anchorsink_mock.go -package=mocks github.com/klaytn/klaytn/kas AnchorSink
+// AnchorSink is anywhere Anchor can submit anchor payloads: the KAS REST
+// API, a Kafka topic mirroring anchors off-chain, an anchor contract on
+// another chain, or any future destination.
+type AnchorSink interface {
+	// Send submits the given payloads and returns one SinkResult per
+	// payload, in the same order. A non-nil error means the sink could not
+	// be reached at all; per-payload failures are reported via SinkResult.
+	Send(ctx context.Context, payloads []*Payload) ([]SinkResult, error)
+	Name() string
+	Close() error
+}
+
+// SinkResult is the per-payload outcome of an AnchorSink.Send call.
+type SinkResult struct {
+	Id      string
+	Success bool
+}
+
+// SinkMode selects how Anchor distributes payloads across its configured
+// sinks.
+type SinkMode int
+
+const (
+	// SinkModeFanout sends every batch of payloads to every configured
+	// sink, so e.g. an off-chain Kafka mirror never falls behind the
+	// primary KAS anchor.
+	SinkModeFanout SinkMode = iota
+	// SinkModePrimaryFallback sends to sinks in configured order, moving to
+	// the next sink only if the previous one returned an error.
+	SinkModePrimaryFallback
+)
+
+// sendToSinks dispatches payloads to anchor.sinks according to
+// kasConfig.SinkMode. In fanout mode the returned results are always the
+// first configured sink's results, since AnchorDB bookkeeping (e.g.
+// WriteAnchoredBlockNumber) tracks the primary anchor destination; in
+// primary-fallback mode they are the results of the first sink that returned
+// no error, and the error is the last sink's when every sink fails. In fanout mode, only a failure of
+// the primary sink itself is returned as an error: a secondary sink (e.g. a
+// Kafka mirror) failing must not block bookkeeping for a primary anchor that
+// otherwise succeeded, so secondary failures are logged and swallowed here.
+func (anchor *Anchor) sendToSinks(ctx context.Context, payloads []*Payload) ([]SinkResult, error) {
+	if anchor.kasConfig.SinkMode == SinkModePrimaryFallback {
+		var lastErr error
+		for _, sink := range anchor.sinks {
+			results, err := sink.Send(ctx, payloads)
+			if err == nil {
+				return results, nil
+			}
+			lastErr = err
+			logger.Warn("Anchor sink failed, falling back to next sink", "sink", sink.Name(), "err", err)
+		}
+		return nil, lastErr
+	}
+
+	var (
+		primaryResults []SinkResult
+		primaryErr     error
+	)
+	for i, sink := range anchor.sinks {
+		results, err := sink.Send(ctx, payloads)
+		if err != nil {
+			logger.Error("Anchor sink failed", "sink", sink.Name(), "err", err)
+			if i == 0 {
+				primaryErr = err
+			}
+			continue
+		}
+		if i == 0 {
+			primaryResults = results
+		}
+	}
+
+	return primaryResults, primaryErr
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/klaytn/klaytn/accounts/abi/bind"
+	"github.com/klaytn/klaytn/blockchain/types"
+	"github.com/klaytn/klaytn/rlp"
+)
+
+//go:generate mockgen -destination=./mocks/anchorcontract_mock.go -package=mocks github.com/klaytn/klaytn/kas AnchorContract
+// AnchorContract is the generated binding of the user-supplied on-chain
+// anchor contract. It submits RLP-encoded anchoring data as a transaction.
+type AnchorContract interface {
+	SubmitAnchor(opts *bind.TransactOpts, data []byte) (*types.Transaction, error)
+}
+
+// ContractSinkConfig configures the on-chain anchor contract sink.
+type ContractSinkConfig struct {
+	Contract AnchorContract
+	Opts     *bind.TransactOpts
+}
+
+// contractSink anchors payloads on another chain by submitting the
+// RLP-encoded AnchoringDataInternalType0 to a user-supplied anchor contract
+// via eth_sendRawTransaction.
+type contractSink struct {
+	cfg *ContractSinkConfig
+}
+
+func newContractSink(cfg *ContractSinkConfig) *contractSink {
+	return &contractSink{cfg: cfg}
+}
+
+func (s *contractSink) Name() string { return "contract" }
+
+func (s *contractSink) Close() error { return nil }
+
+// Send submits one contract transaction per payload. It copies cfg.Opts per
+// call rather than mutating the shared *bind.TransactOpts, since concurrent
+// callers (e.g. Anchor.BackfillRange's worker pool) share the same
+// contractSink.
+func (s *contractSink) Send(ctx context.Context, payloads []*Payload) ([]SinkResult, error) {
+	opts := *s.cfg.Opts
+	opts.Context = ctx
+
+	results := make([]SinkResult, len(payloads))
+
+	for i, payload := range payloads {
+		data, err := rlp.EncodeToBytes(&payload.AnchoringDataInternalType0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to RLP-encode payload %v for contract anchor: %w", payload.Id, err)
+		}
+
+		if _, err := s.cfg.Contract.SubmitAnchor(&opts, data); err != nil {
+			return nil, fmt.Errorf("failed to submit payload %v to anchor contract: %w", payload.Id, err)
+		}
+
+		results[i] = SinkResult{Id: payload.Id, Success: true}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var errKafkaPartitionsNotSet = errors.New("kas: KafkaSinkConfig.Partitions must be greater than zero")
+
+//go:generate mockgen -destination=./mocks/kafkaproducer_mock.go -package=mocks github.com/klaytn/klaytn/kas KafkaProducer
+// KafkaProducer abstracts over the Kafka client library so kafkaSink stays
+// testable without a broker.
+type KafkaProducer interface {
+	Produce(topic string, partition int32, key, value []byte) error
+	Close() error
+}
+
+// KafkaSinkConfig configures the Kafka mirror sink.
+type KafkaSinkConfig struct {
+	Producer   KafkaProducer
+	Topic      string
+	Partitions int32
+}
+
+// kafkaSink mirrors anchor payloads to a Kafka topic for off-chain
+// analytics, keyed by block number so a given block always lands on the
+// same partition.
+type kafkaSink struct {
+	cfg *KafkaSinkConfig
+}
+
+func newKafkaSink(cfg *KafkaSinkConfig) (*kafkaSink, error) {
+	if cfg.Partitions <= 0 {
+		return nil, errKafkaPartitionsNotSet
+	}
+	return &kafkaSink{cfg: cfg}, nil
+}
+
+func (s *kafkaSink) Name() string { return "kafka" }
+
+func (s *kafkaSink) Close() error { return s.cfg.Producer.Close() }
+
+// Send produces one JSON-encoded message per payload, partitioned by
+// BlockNumber % partitions.
+func (s *kafkaSink) Send(ctx context.Context, payloads []*Payload) ([]SinkResult, error) {
+	results := make([]SinkResult, len(payloads))
+
+	for i, payload := range payloads {
+		value, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload %v for kafka: %w", payload.Id, err)
+		}
+
+		partition := int32(payload.BlockNumber.Uint64() % uint64(s.cfg.Partitions))
+		key := []byte(payload.Id)
+
+		if err := s.cfg.Producer.Produce(s.cfg.Topic, partition, key, value); err != nil {
+			return nil, fmt.Errorf("failed to produce payload %v to kafka: %w", payload.Id, err)
+		}
+
+		results[i] = SinkResult{Id: payload.Id, Success: true}
+	}
+
+	return results, nil
+}
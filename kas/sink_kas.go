@@ -0,0 +1,284 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/klaytn/klaytn/common"
+)
+
+//go:generate mockgen -destination=./mocks/client_mock.go -package=mocks github.com/klaytn/klaytn/kas HTTPClient
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type respBody struct {
+	Code   int         `json:"code"`
+	Result interface{} `json:"result"`
+}
+
+type reqBody struct {
+	Operator common.Address `json:"operator"`
+	Payload  interface{}    `json:"Payload"`
+}
+
+// batchReqBody posts a slice of payloads under a single "payloads" field,
+// amortizing one HTTP request over many anchors.
+type batchReqBody struct {
+	Operator common.Address `json:"operator"`
+	Payloads []*Payload     `json:"payloads"`
+}
+
+// respItem is the per-payload result embedded in a batchRespBody.Result.
+type respItem struct {
+	Id   string `json:"id"`
+	Code int    `json:"code"`
+}
+
+type batchRespBody struct {
+	Code   int        `json:"code"`
+	Result []respItem `json:"result"`
+}
+
+// kasHTTPSink anchors payloads via the KAS anchor REST API. It owns the
+// resiliency policy (retries, backoff, circuit breaker) configured on
+// KASConfig.
+type kasHTTPSink struct {
+	kasConfig *KASConfig
+	client    HTTPClient
+	breaker   *circuitBreaker
+}
+
+func newKASHTTPSink(kasConfig *KASConfig) *kasHTTPSink {
+	return &kasHTTPSink{
+		kasConfig: kasConfig,
+		client:    &http.Client{},
+		breaker:   newCircuitBreaker(kasConfig.BreakerThreshold, kasConfig.BreakerCooldown),
+	}
+}
+
+func (s *kasHTTPSink) Name() string { return "kas-http" }
+
+func (s *kasHTTPSink) Close() error { return nil }
+
+// Send anchors the given payloads, using the single-payload endpoint for one
+// payload and the batch endpoint otherwise.
+func (s *kasHTTPSink) Send(ctx context.Context, payloads []*Payload) ([]SinkResult, error) {
+	if len(payloads) == 1 {
+		res, err := s.sendRequest(ctx, payloads[0])
+		if err != nil {
+			return nil, err
+		}
+		return []SinkResult{{Id: payloads[0].Id, Success: res.Code == codeOK}}, nil
+	}
+
+	items, err := s.sendBatchRequest(ctx, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SinkResult, len(payloads))
+	for i, payload := range payloads {
+		results[i] = SinkResult{
+			Id:      payload.Id,
+			Success: i < len(items) && items[i].Code == codeOK,
+		}
+	}
+	return results, nil
+}
+
+// sendRequest requests to KAS anchor API with given payload. Network errors,
+// 5xx responses, and 429 responses are retried with exponential backoff and
+// jitter up to kasConfig.MaxRetries attempts; other 4xx responses are
+// surfaced immediately. Repeated failures trip a circuit breaker that fails
+// fast for kasConfig.BreakerCooldown before letting a single probe request
+// through.
+func (s *kasHTTPSink) sendRequest(ctx context.Context, payload interface{}) (*respBody, error) {
+	res, err := s.executeWithRetry(func() (interface{}, error, bool) {
+		return s.doRequest(ctx, payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*respBody), nil
+}
+
+// sendBatchRequest posts a batch of payloads in a single HTTP request and
+// returns the per-item results parsed out of the response's Result array, in
+// the same order as payloads.
+func (s *kasHTTPSink) sendBatchRequest(ctx context.Context, payloads []*Payload) ([]respItem, error) {
+	res, err := s.executeWithRetry(func() (interface{}, error, bool) {
+		return s.doBatchRequest(ctx, payloads)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*batchRespBody).Result, nil
+}
+
+// executeWithRetry runs attempt, retrying retryable failures with
+// exponential backoff and jitter for up to kasConfig.MaxRetries attempts in
+// all, and updates the circuit breaker based on the final outcome. It
+// records the kas/anchor/requests/{ok,error,retry} counters and the
+// kas/anchor/request/duration timer.
+func (s *kasHTTPSink) executeWithRetry(attempt func() (interface{}, error, bool)) (interface{}, error) {
+	if !s.breaker.Allow() {
+		anchorRequestsErrorCounter.Inc(1)
+		return nil, errBreakerOpen
+	}
+
+	defer anchorRequestDurationTimer.UpdateSince(time.Now())
+
+	var (
+		res       interface{}
+		err       error
+		retryable bool
+	)
+
+	maxRetries := s.kasConfig.MaxRetries
+	for i := 0; i < maxRetries; i++ {
+		res, err, retryable = attempt()
+		if err == nil {
+			anchorRequestsOKCounter.Inc(1)
+			s.breaker.RecordSuccess()
+			return res, nil
+		}
+
+		if !retryable {
+			anchorRequestsErrorCounter.Inc(1)
+			s.breaker.RecordFailure()
+			return nil, err
+		}
+
+		anchorRequestsRetryCounter.Inc(1)
+		if i < maxRetries-1 {
+			logger.Debug("Retrying KAS anchor request", "attempt", i+1, "err", err)
+			time.Sleep(s.retryDelay(i))
+		}
+	}
+
+	anchorRequestsErrorCounter.Inc(1)
+	s.breaker.RecordFailure()
+	return nil, err
+}
+
+// doRequest performs a single HTTP round trip to the KAS anchor API. The
+// returned bool reports whether the error (if any) is retryable.
+func (s *kasHTTPSink) doRequest(ctx context.Context, payload interface{}) (*respBody, error, bool) {
+	bodyData := reqBody{
+		Operator: s.kasConfig.Operator,
+		Payload:  payload,
+	}
+
+	bodyDataBytes, err := json.Marshal(bodyData)
+	if err != nil {
+		return nil, err, false
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, s.kasConfig.RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "POST", s.kasConfig.Url, bytes.NewReader(bodyDataBytes))
+	if err != nil {
+		return nil, err, false
+	}
+	req.SetBasicAuth(s.kasConfig.User, s.kasConfig.Pwd)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Krn", s.kasConfig.Xkrn)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err, true
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("retryable KAS response status %v", resp.StatusCode), true
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("KAS response status %v", resp.StatusCode), false
+	}
+
+	v := respBody{}
+	json.NewDecoder(resp.Body).Decode(&v)
+
+	return &v, nil, false
+}
+
+// doBatchRequest performs a single HTTP round trip posting a batch of
+// payloads. The returned bool reports whether the error (if any) is
+// retryable.
+func (s *kasHTTPSink) doBatchRequest(ctx context.Context, payloads []*Payload) (*batchRespBody, error, bool) {
+	bodyData := batchReqBody{
+		Operator: s.kasConfig.Operator,
+		Payloads: payloads,
+	}
+
+	bodyDataBytes, err := json.Marshal(bodyData)
+	if err != nil {
+		return nil, err, false
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, s.kasConfig.RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "POST", s.kasConfig.Url, bytes.NewReader(bodyDataBytes))
+	if err != nil {
+		return nil, err, false
+	}
+	req.SetBasicAuth(s.kasConfig.User, s.kasConfig.Pwd)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Krn", s.kasConfig.Xkrn)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err, true
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("retryable KAS response status %v", resp.StatusCode), true
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("KAS response status %v", resp.StatusCode), false
+	}
+
+	v := batchRespBody{}
+	json.NewDecoder(resp.Body).Decode(&v)
+
+	return &v, nil, false
+}
+
+// retryDelay computes the exponential backoff with jitter for the given
+// (zero-indexed) retry attempt, capped at RetryMaxDelay.
+func (s *kasHTTPSink) retryDelay(attempt int) time.Duration {
+	cfg := s.kasConfig
+
+	delay := cfg.RetryBaseDelay * time.Duration(uint64(1)<<uint(attempt))
+	if delay <= 0 || delay > cfg.RetryMaxDelay {
+		delay = cfg.RetryMaxDelay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/klaytn/klaytn/accounts/abi/bind"
+	"github.com/klaytn/klaytn/blockchain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSink is a minimal AnchorSink used to exercise Anchor's fan-out and
+// primary/fallback dispatch logic without a real network call.
+type fakeSink struct {
+	name    string
+	results []SinkResult
+	err     error
+	calls   int
+}
+
+func (s *fakeSink) Name() string { return s.name }
+func (s *fakeSink) Close() error { return nil }
+func (s *fakeSink) Send(ctx context.Context, payloads []*Payload) ([]SinkResult, error) {
+	s.calls++
+	return s.results, s.err
+}
+
+func TestSendToSinksFanoutCallsEverySink(t *testing.T) {
+	primary := &fakeSink{name: "primary", results: []SinkResult{{Id: "1", Success: true}}}
+	mirror := &fakeSink{name: "mirror", results: []SinkResult{{Id: "1", Success: true}}}
+
+	anchor := &Anchor{
+		kasConfig: &KASConfig{SinkMode: SinkModeFanout},
+		sinks:     []AnchorSink{primary, mirror},
+	}
+
+	pl := dataToPayload(testAnchorData())
+	results, err := anchor.sendToSinks(context.Background(), []*Payload{pl})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, primary.calls)
+	assert.Equal(t, 1, mirror.calls)
+	assert.Equal(t, primary.results, results)
+}
+
+func TestSendToSinksFanoutSecondarySinkFailureDoesNotFailCall(t *testing.T) {
+	failErr := errors.New("mirror unavailable")
+	primary := &fakeSink{name: "primary", results: []SinkResult{{Id: "1", Success: true}}}
+	mirror := &fakeSink{name: "mirror", err: failErr}
+
+	anchor := &Anchor{
+		kasConfig: &KASConfig{SinkMode: SinkModeFanout},
+		sinks:     []AnchorSink{primary, mirror},
+	}
+
+	pl := dataToPayload(testAnchorData())
+	results, err := anchor.sendToSinks(context.Background(), []*Payload{pl})
+
+	assert.NoError(t, err)
+	assert.Equal(t, primary.results, results)
+	assert.Equal(t, 1, mirror.calls)
+}
+
+func TestSendToSinksFanoutSurfacesPrimaryError(t *testing.T) {
+	failErr := errors.New("primary unavailable")
+	primary := &fakeSink{name: "primary", err: failErr}
+	mirror := &fakeSink{name: "mirror", results: []SinkResult{{Id: "1", Success: true}}}
+
+	anchor := &Anchor{
+		kasConfig: &KASConfig{SinkMode: SinkModeFanout},
+		sinks:     []AnchorSink{primary, mirror},
+	}
+
+	pl := dataToPayload(testAnchorData())
+	results, err := anchor.sendToSinks(context.Background(), []*Payload{pl})
+
+	assert.Equal(t, failErr, err)
+	assert.Nil(t, results)
+	assert.Equal(t, 1, mirror.calls)
+}
+
+func TestSendToSinksPrimaryFallback(t *testing.T) {
+	failErr := errors.New("primary unavailable")
+	primary := &fakeSink{name: "primary", err: failErr}
+	fallback := &fakeSink{name: "fallback", results: []SinkResult{{Id: "1", Success: true}}}
+
+	anchor := &Anchor{
+		kasConfig: &KASConfig{SinkMode: SinkModePrimaryFallback},
+		sinks:     []AnchorSink{primary, fallback},
+	}
+
+	pl := dataToPayload(testAnchorData())
+	results, err := anchor.sendToSinks(context.Background(), []*Payload{pl})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, primary.calls)
+	assert.Equal(t, 1, fallback.calls)
+	assert.Equal(t, fallback.results, results)
+}
+
+// fakeKafkaProducer is a minimal KafkaProducer used to test kafkaSink
+// without a broker.
+type fakeKafkaProducer struct {
+	produced []struct {
+		topic     string
+		partition int32
+		key       []byte
+		value     []byte
+	}
+}
+
+func (p *fakeKafkaProducer) Produce(topic string, partition int32, key, value []byte) error {
+	p.produced = append(p.produced, struct {
+		topic     string
+		partition int32
+		key       []byte
+		value     []byte
+	}{topic, partition, key, value})
+	return nil
+}
+
+func (p *fakeKafkaProducer) Close() error { return nil }
+
+func TestNewKafkaSinkRejectsZeroPartitions(t *testing.T) {
+	producer := &fakeKafkaProducer{}
+	sink, err := newKafkaSink(&KafkaSinkConfig{Producer: producer, Topic: "anchors"})
+
+	assert.Equal(t, errKafkaPartitionsNotSet, err)
+	assert.Nil(t, sink)
+}
+
+func TestKafkaSinkSendPartitionsByBlockNumber(t *testing.T) {
+	producer := &fakeKafkaProducer{}
+	sink, err := newKafkaSink(&KafkaSinkConfig{Producer: producer, Topic: "anchors", Partitions: 4})
+	assert.NoError(t, err)
+
+	pl := dataToPayload(testAnchorData()) // BlockNumber: 5
+
+	results, err := sink.Send(context.Background(), []*Payload{pl})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []SinkResult{{Id: pl.Id, Success: true}}, results)
+	assert.Len(t, producer.produced, 1)
+	assert.Equal(t, "anchors", producer.produced[0].topic)
+	assert.Equal(t, int32(5%4), producer.produced[0].partition)
+}
+
+// fakeAnchorContract is a minimal AnchorContract used to test contractSink
+// without a real chain connection.
+type fakeAnchorContract struct {
+	submitted [][]byte
+}
+
+func (c *fakeAnchorContract) SubmitAnchor(opts *bind.TransactOpts, data []byte) (*types.Transaction, error) {
+	c.submitted = append(c.submitted, data)
+	return nil, nil
+}
+
+func TestContractSinkSendSubmitsRLPEncodedPayload(t *testing.T) {
+	contract := &fakeAnchorContract{}
+	sink := newContractSink(&ContractSinkConfig{Contract: contract, Opts: &bind.TransactOpts{}})
+
+	pl := dataToPayload(testAnchorData())
+
+	results, err := sink.Send(context.Background(), []*Payload{pl})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []SinkResult{{Id: pl.Id, Success: true}}, results)
+	assert.Len(t, contract.submitted, 1)
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package kas
+
+// go.opentelemetry.io/otel is a new external dependency introduced by this
+// package; it must be present in go.mod/go.sum (or vendored) alongside the
+// klaytn/klaytn/metrics dependency the rest of this package already uses.
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracer emits spans for the anchor pipeline under the "klaytn/kas"
+// instrumentation name.
+var tracer = otel.Tracer("klaytn/kas")
+
+// anchorSpanAttributes builds the common attribute set attached to every
+// anchor span: the block number, the transaction count covered by the
+// anchoring data, and the KAS chain identifier (xkrn) being anchored to.
+func anchorSpanAttributes(blkNum uint64, txCount int64, xkrn string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("blkNum", int64(blkNum)),
+		attribute.Int64("txCount", txCount),
+		attribute.String("xkrn", xkrn),
+	}
+}